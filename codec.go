@@ -0,0 +1,90 @@
+package exr
+
+// A Codec decompresses the pixel data of a single chunk (scanline
+// block or tile). dst must already be sized to hold lineSize*numLines
+// bytes of decompressed, channel-interleaved pixel data; src holds
+// the chunk's compressed bytes exactly as stored in the file.
+type Codec interface {
+	Decompress(dst, src []byte, lineSize, numLines int) error
+}
+
+var codecs = map[compression]Codec{}
+
+// RegisterCodec registers a Codec for the given compression method,
+// analogous to archive/zip.RegisterDecompressor. Calling RegisterCodec
+// again for a method already built in (e.g. PIZ) replaces it, which
+// lets callers drop in their own implementation of the codecs this
+// package only stubs out.
+func RegisterCodec(c compression, codec Codec) {
+	codecs[c] = codec
+}
+
+func init() {
+	RegisterCodec(NO_COMPRESSION, copyCodec{})
+	RegisterCodec(RLE_COMPRESSION, rleCodec{})
+	RegisterCodec(ZIP_COMPRESSION, zipCodec{})
+	RegisterCodec(ZIPS_COMPRESSION, zipCodec{})
+	// TODO(chunk0-2): PXR24 is registered as unsupported rather than
+	// implemented. Reassembling its byte planes back into samples needs
+	// each channel's pixel type (PXR24 stores FLOAT as 3 planes,
+	// dropping the low byte of precision, and HALF/UINT differently),
+	// but Codec.Decompress only receives lineSize and numLines -- the
+	// per-channel layout Reader.DecodeChannel knows isn't threaded
+	// through. A prior attempt at this (4891efb) shipped exactly that
+	// gap: it deflated correctly but just copied the raw planes into
+	// dst, silently returning garbage pixels while reporting success.
+	// Doing this right means extending the Codec interface with a
+	// channel layout, which every other codec would also need to
+	// accept; that's a bigger change than this request's scope, so
+	// this is registered honestly unsupported instead.
+	RegisterCodec(PXR24_COMPRESSION, unsupportedCodec("PXR24"))
+	RegisterCodec(PIZ_COMPRESSION, unsupportedCodec("PIZ"))
+	RegisterCodec(B44_COMPRESSION, unsupportedCodec("B44"))
+	RegisterCodec(B44A_COMPRESSION, unsupportedCodec("B44A"))
+}
+
+// copyCodec implements NO_COMPRESSION: the chunk already holds raw,
+// channel-interleaved pixel data.
+type copyCodec struct{}
+
+func (copyCodec) Decompress(dst, src []byte, lineSize, numLines int) error {
+	if len(src) != len(dst) {
+		return FormatError("uncompressed chunk size does not match line size")
+	}
+	copy(dst, src)
+	return nil
+}
+
+// unsupportedCodec is a placeholder for compression methods this
+// package does not implement yet. Callers can replace it with a real
+// Codec via RegisterCodec.
+type unsupportedCodec string
+
+func (c unsupportedCodec) Decompress(dst, src []byte, lineSize, numLines int) error {
+	return UnsupportedError(string(c) + " compression")
+}
+
+// unpredict reverses EXR's byte-delta prediction pass, applied in
+// place left to right: p[i] = p[i-1] + p[i] - 128 (mod 256).
+func unpredict(p []byte) {
+	for i := 1; i < len(p); i++ {
+		p[i] = p[i-1] + p[i] - 128
+	}
+}
+
+// deinterleave reverses EXR's even/odd byte split, used by both ZIP
+// and RLE-adjacent codecs: the first half of src holds the
+// even-indexed output bytes and the second half holds the odd-indexed
+// ones.
+func deinterleave(src []byte) []byte {
+	dst := make([]byte, len(src))
+	half := (len(src) + 1) / 2
+	for i := 0; i < len(src); i++ {
+		if i%2 == 0 {
+			dst[i] = src[i/2]
+		} else {
+			dst[i] = src[half+i/2]
+		}
+	}
+	return dst
+}
@@ -0,0 +1,51 @@
+package exr
+
+// rleCodec implements EXR's RLE compression: a signed-byte run-length
+// encoding applied to the byte-delta-predicted, even/odd-interleaved
+// scanline data.
+type rleCodec struct{}
+
+func (rleCodec) Decompress(dst, src []byte, lineSize, numLines int) error {
+	raw, err := rleDecode(src, lineSize*numLines)
+	if err != nil {
+		return err
+	}
+	unpredict(raw)
+	copy(dst, deinterleave(raw))
+	return nil
+}
+
+// rleDecode reverses EXR's run-length encoding into a buffer of
+// exactly size bytes. For each run byte b: if b >= 0 the single byte
+// that follows is repeated b+1 times; if b < 0 the next -b bytes are
+// copied literally.
+func rleDecode(src []byte, size int) ([]byte, error) {
+	dst := make([]byte, 0, size)
+	i := 0
+	for i < len(src) {
+		b := int8(src[i])
+		i++
+		if b >= 0 {
+			if i >= len(src) {
+				return nil, FormatError("truncated RLE repeat run")
+			}
+			n := int(b) + 1
+			v := src[i]
+			i++
+			for j := 0; j < n; j++ {
+				dst = append(dst, v)
+			}
+		} else {
+			n := -int(b)
+			if i+n > len(src) {
+				return nil, FormatError("truncated RLE literal run")
+			}
+			dst = append(dst, src[i:i+n]...)
+			i += n
+		}
+	}
+	if len(dst) != size {
+		return nil, FormatError("RLE decoded size does not match expected chunk size")
+	}
+	return dst, nil
+}
@@ -0,0 +1,58 @@
+package exr
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestRLEDecode checks rleDecode against hand-built byte sequences
+// using OpenEXR's actual run-length convention (ImfRle.cpp): a
+// non-negative count byte b repeats the following byte b+1 times; a
+// negative count byte b is followed by -b literal bytes.
+func TestRLEDecode(t *testing.T) {
+	cases := []struct {
+		name string
+		src  []byte
+		want []byte
+	}{
+		{
+			name: "repeat run",
+			src:  []byte{2, 0xAA}, // b=2 -> 3 copies of 0xAA
+			want: []byte{0xAA, 0xAA, 0xAA},
+		},
+		{
+			name: "literal run",
+			src:  []byte{0xFD, 0x01, 0x02, 0x03}, // b=-3 -> 3 literal bytes
+			want: []byte{0x01, 0x02, 0x03},
+		},
+		{
+			name: "mixed runs",
+			src: []byte{
+				0xFE, 0x10, 0x11, // literal: 0x10, 0x11
+				1, 0x20, // repeat: 0x20, 0x20
+			},
+			want: []byte{0x10, 0x11, 0x20, 0x20},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := rleDecode(c.src, len(c.want))
+			if err != nil {
+				t.Fatalf("rleDecode: %v", err)
+			}
+			if !bytes.Equal(got, c.want) {
+				t.Errorf("rleDecode(%v) = %v, want %v", c.src, got, c.want)
+			}
+		})
+	}
+}
+
+func TestRLEDecodeTruncated(t *testing.T) {
+	if _, err := rleDecode([]byte{2}, 3); err == nil {
+		t.Error("rleDecode: expected error for truncated repeat run, got nil")
+	}
+	if _, err := rleDecode([]byte{0xFD, 0x01}, 3); err == nil {
+		t.Error("rleDecode: expected error for truncated literal run, got nil")
+	}
+}
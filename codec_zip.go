@@ -0,0 +1,31 @@
+package exr
+
+import (
+	"bytes"
+	"compress/zlib"
+	"io"
+)
+
+// zipCodec implements EXR's ZIP and ZIPS compression: zlib-compressed
+// data that, before compression, was passed through a byte-delta
+// prediction pass and an even/odd byte interleave. ZIP and ZIPS only
+// differ in how many scanlines are grouped per chunk (see
+// numLinesPerBlock), not in the codec itself.
+type zipCodec struct{}
+
+func (zipCodec) Decompress(dst, src []byte, lineSize, numLines int) error {
+	zr, err := zlib.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	raw := make([]byte, lineSize*numLines)
+	if _, err := io.ReadFull(zr, raw); err != nil {
+		return err
+	}
+
+	unpredict(raw)
+	copy(dst, deinterleave(raw))
+	return nil
+}
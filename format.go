@@ -0,0 +1,35 @@
+package exr
+
+// compression identifies a chunk's compression method, as stored in
+// the 1-byte "compression" header attribute.
+type compression uint8
+
+const (
+	NO_COMPRESSION compression = iota
+	RLE_COMPRESSION
+	ZIPS_COMPRESSION
+	ZIP_COMPRESSION
+	PIZ_COMPRESSION
+	PXR24_COMPRESSION
+	B44_COMPRESSION
+	B44A_COMPRESSION
+)
+
+// maxImagePixels bounds a part's dataWindow width*height. dataWindow
+// is four attacker-controlled int32s, so without a sanity limit a
+// crafted header can make every per-pixel buffer this package
+// allocates (DecodeChannel's output slice, Image's RGBAFloat/Gray32f)
+// arbitrarily large. 1<<28 is generous -- about 16384x16384 -- while
+// still being far short of exhausting memory on its own.
+const maxImagePixels = 1 << 28
+
+// channel describes one entry of a chlist header attribute: a single
+// named channel (e.g. "R", "G", "B", "A", "Y") and how its samples are
+// stored.
+type channel struct {
+	name      string
+	pixelType int32 // 0: UINT, 1: HALF, 2: FLOAT
+	pLinear   uint8
+	xSampling int32
+	ySampling int32
+}
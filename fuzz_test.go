@@ -0,0 +1,38 @@
+package exr
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// FuzzDecode feeds arbitrary bytes through NewReader and Image,
+// seeded from the fixtures in testdata/*.exr. parseAttribute is the
+// main attack surface here: a crafted header can claim an attribute
+// or chunk count far beyond what the input actually holds, so this
+// mostly exists to catch allocation panics and out-of-range slice
+// accesses rather than to check decoded pixel values.
+func FuzzDecode(f *testing.F) {
+	matches, err := filepath.Glob("testdata/*.exr")
+	if err != nil {
+		f.Fatalf("Glob: %v", err)
+	}
+	for _, m := range matches {
+		data, err := os.ReadFile(m)
+		if err != nil {
+			f.Fatalf("ReadFile(%q): %v", m, err)
+		}
+		f.Add(data)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return
+		}
+		// Image and DecodeChannel only support single-part, untiled
+		// parts; anything else already returns an UnsupportedError.
+		_, _ = r.Image()
+	})
+}
@@ -0,0 +1,56 @@
+package exr
+
+import "math"
+
+// halfToFloat32 converts an IEEE-754 binary16 (half-precision float),
+// as used by EXR's HALF pixel type, to a float32.
+func halfToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h&0x7c00) >> 10
+	frac := uint32(h & 0x03ff)
+
+	switch exp {
+	case 0:
+		if frac == 0 {
+			// Signed zero.
+			return math.Float32frombits(sign)
+		}
+		// Subnormal: normalize by shifting the fraction left until
+		// the implicit leading bit would be set, adjusting the
+		// exponent to match.
+		e := int32(-1)
+		for frac&0x0400 == 0 {
+			frac <<= 1
+			e--
+		}
+		frac &= 0x03ff
+		bits := sign | uint32(int32(127-15)+e+1)<<23 | frac<<13
+		return math.Float32frombits(bits)
+	case 0x1f:
+		// Inf / NaN.
+		bits := sign | 0xff<<23 | frac<<13
+		return math.Float32frombits(bits)
+	default:
+		bits := sign | (exp-15+127)<<23 | frac<<13
+		return math.Float32frombits(bits)
+	}
+}
+
+// float32ToHalf converts a float32 to an IEEE-754 binary16 value,
+// truncating mantissa precision. Values too small to represent flush
+// to zero; values too large saturate to +/-Inf.
+func float32ToHalf(f float32) uint16 {
+	bits := math.Float32bits(f)
+	sign := uint16((bits >> 16) & 0x8000)
+	exp := int32((bits>>23)&0xff) - 127 + 15
+	frac := bits & 0x7fffff
+
+	switch {
+	case exp <= 0:
+		return sign
+	case exp >= 0x1f:
+		return sign | 0x7c00
+	default:
+		return sign | uint16(exp)<<10 | uint16(frac>>13)
+	}
+}
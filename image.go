@@ -0,0 +1,248 @@
+package exr
+
+import (
+	"bufio"
+	"bytes"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+)
+
+func init() {
+	// 20000630 little-endian, as required by image.RegisterFormat.
+	image.RegisterFormat("exr", "\x76\x2f\x31\x01", decodeReader, DecodeConfig)
+}
+
+// decodeReader adapts NewReader's io.ReaderAt-based pipeline to the
+// func(io.Reader) (image.Image, error) signature image.RegisterFormat
+// requires, which Decode's path-based signature doesn't satisfy.
+func decodeReader(r io.Reader) (image.Image, error) {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	br := bytes.NewReader(buf)
+	rd, err := NewReader(br, int64(len(buf)))
+	if err != nil {
+		return nil, err
+	}
+	return rd.Image()
+}
+
+// RGBAFloatColor is a float32 RGBA color in whatever scene-linear
+// working space the image was authored in. Unlike color.RGBA64, its
+// components are not clamped to [0, 1] and are not gamma-encoded;
+// RGBA satisfies the color.Color interface by clamping on the way
+// out.
+type RGBAFloatColor struct {
+	R, G, B, A float32
+}
+
+func (c RGBAFloatColor) RGBA() (r, g, b, a uint32) {
+	return floatToRGBA(c.R), floatToRGBA(c.G), floatToRGBA(c.B), floatToRGBA(c.A)
+}
+
+func floatToRGBA(v float32) uint32 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 1:
+		return 0xffff
+	default:
+		return uint32(v * 0xffff)
+	}
+}
+
+// RGBAFloatModel converts arbitrary colors to RGBAFloatColor.
+var RGBAFloatModel = color.ModelFunc(rgbaFloatModel)
+
+func rgbaFloatModel(c color.Color) color.Color {
+	if rc, ok := c.(RGBAFloatColor); ok {
+		return rc
+	}
+	r, g, b, a := c.RGBA()
+	return RGBAFloatColor{
+		R: float32(r) / 0xffff,
+		G: float32(g) / 0xffff,
+		B: float32(b) / 0xffff,
+		A: float32(a) / 0xffff,
+	}
+}
+
+// RGBAFloat is an in-memory image of float32 R, G, B, A samples,
+// stored uncompressed and without any tone mapping, for EXR images
+// with R, G, B (and optionally A) channels.
+type RGBAFloat struct {
+	// Pix holds R, G, B, A samples per pixel, in row-major order.
+	Pix  []float32
+	Rect image.Rectangle
+}
+
+// NewRGBAFloat returns a new RGBAFloat with the given bounds.
+func NewRGBAFloat(r image.Rectangle) *RGBAFloat {
+	return &RGBAFloat{Pix: make([]float32, 4*r.Dx()*r.Dy()), Rect: r}
+}
+
+func (im *RGBAFloat) ColorModel() color.Model { return RGBAFloatModel }
+func (im *RGBAFloat) Bounds() image.Rectangle { return im.Rect }
+
+func (im *RGBAFloat) At(x, y int) color.Color {
+	if !(image.Point{X: x, Y: y}.In(im.Rect)) {
+		return RGBAFloatColor{}
+	}
+	i := im.PixOffset(x, y)
+	return RGBAFloatColor{R: im.Pix[i], G: im.Pix[i+1], B: im.Pix[i+2], A: im.Pix[i+3]}
+}
+
+// PixOffset returns the index of the first sample of the pixel at
+// (x, y) within Pix.
+func (im *RGBAFloat) PixOffset(x, y int) int {
+	return 4 * ((y-im.Rect.Min.Y)*im.Rect.Dx() + (x - im.Rect.Min.X))
+}
+
+func (im *RGBAFloat) Set(x, y int, c color.Color) {
+	if !(image.Point{X: x, Y: y}.In(im.Rect)) {
+		return
+	}
+	rc := RGBAFloatModel.Convert(c).(RGBAFloatColor)
+	i := im.PixOffset(x, y)
+	im.Pix[i], im.Pix[i+1], im.Pix[i+2], im.Pix[i+3] = rc.R, rc.G, rc.B, rc.A
+}
+
+// Gray32fColor is a single float32 luminance sample.
+type Gray32fColor float32
+
+func (c Gray32fColor) RGBA() (r, g, b, a uint32) {
+	v := floatToRGBA(float32(c))
+	return v, v, v, 0xffff
+}
+
+// Gray32fModel converts arbitrary colors to Gray32fColor.
+var Gray32fModel = color.ModelFunc(gray32fModel)
+
+func gray32fModel(c color.Color) color.Color {
+	if gc, ok := c.(Gray32fColor); ok {
+		return gc
+	}
+	r, _, _, _ := c.RGBA()
+	return Gray32fColor(float32(r) / 0xffff)
+}
+
+// Gray32f is an in-memory image of float32 luminance samples, for EXR
+// images that only have a Y channel.
+type Gray32f struct {
+	Pix  []float32
+	Rect image.Rectangle
+}
+
+// NewGray32f returns a new Gray32f with the given bounds.
+func NewGray32f(r image.Rectangle) *Gray32f {
+	return &Gray32f{Pix: make([]float32, r.Dx()*r.Dy()), Rect: r}
+}
+
+func (im *Gray32f) ColorModel() color.Model { return Gray32fModel }
+func (im *Gray32f) Bounds() image.Rectangle { return im.Rect }
+
+func (im *Gray32f) At(x, y int) color.Color {
+	if !(image.Point{X: x, Y: y}.In(im.Rect)) {
+		return Gray32fColor(0)
+	}
+	return Gray32fColor(im.Pix[im.PixOffset(x, y)])
+}
+
+// PixOffset returns the index of the pixel at (x, y) within Pix.
+func (im *Gray32f) PixOffset(x, y int) int {
+	return (y-im.Rect.Min.Y)*im.Rect.Dx() + (x - im.Rect.Min.X)
+}
+
+func (im *Gray32f) Set(x, y int, c color.Color) {
+	if !(image.Point{X: x, Y: y}.In(im.Rect)) {
+		return
+	}
+	im.Pix[im.PixOffset(x, y)] = float32(Gray32fModel.Convert(c).(Gray32fColor))
+}
+
+// DecodeConfig parses only as much of r as is needed to report the
+// image's dimensions and color model: the magic number, version field
+// and header attributes, stopping short of the chunk offset table.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	br := bufio.NewReader(r)
+
+	magicByte, err := read(br, 4)
+	if err != nil {
+		return image.Config{}, err
+	}
+	if int(parse.Uint32(magicByte)) != MagicNumber {
+		return image.Config{}, FormatError("wrong magic number")
+	}
+
+	versionBytes, err := read(br, 4)
+	if err != nil {
+		return image.Config{}, err
+	}
+	versionNum := int(parse.Uint32(versionBytes))
+	if versionNum&0x1000 != 0 {
+		return image.Config{}, UnsupportedError("multi-part image")
+	}
+	longName := versionNum&0x400 != 0
+
+	// r is a plain io.Reader with no known total length, so bound each
+	// attribute and the header as a whole against maxHeaderSize instead
+	// of an exact stream-length budget.
+	remaining := int64(maxHeaderSize)
+	header := make(map[string]attribute)
+	for {
+		pAttr, err := parseAttribute(br, parse, longName, remaining)
+		if err != nil {
+			return image.Config{}, err
+		}
+		if pAttr == nil {
+			break
+		}
+		remaining -= int64(len(pAttr.name)) + 1 + int64(len(pAttr.typ)) + 1 + 4 + int64(pAttr.size)
+		header[pAttr.name] = *pAttr
+	}
+
+	dataWindow, ok := header["dataWindow"]
+	if !ok {
+		return image.Config{}, FormatError("header does not have 'dataWindow' attribute")
+	}
+	xMin, yMin, xMax, yMax, err := parseBox2i(dataWindow.value)
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	model := color.Model(Gray32fModel)
+	if channels, ok := header["channels"]; ok && hasChannelName(channels.value, "R") {
+		model = RGBAFloatModel
+	}
+
+	return image.Config{
+		ColorModel: model,
+		Width:      xMax - xMin + 1,
+		Height:     yMax - yMin + 1,
+	}, nil
+}
+
+// hasChannelName reports whether the encoded chlist attribute value
+// declares a channel with the given name.
+func hasChannelName(chlist []byte, name string) bool {
+	remain := bufio.NewReader(bytes.NewBuffer(chlist))
+	for {
+		nameByte, err := remain.ReadBytes(0x00)
+		if err != nil {
+			return false
+		}
+		n := string(nameByte[:len(nameByte)-1])
+		if n == "" {
+			return false
+		}
+		if n == name {
+			return true
+		}
+		if _, err := read(remain, 16); err != nil {
+			return false
+		}
+	}
+}
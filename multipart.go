@@ -0,0 +1,420 @@
+package exr
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Part holds one part's header and chunk offset table. Single-part
+// files are modeled as a file with exactly one Part; Reader exposes
+// it both through its own single-part convenience methods and through
+// Parts.
+type Part struct {
+	ra     io.ReaderAt
+	header map[string]attribute
+
+	name        string
+	typ         string // "scanlineimage", "tiledimage", "deepscanline" or "deeptile"
+	channels    []channel
+	xMin, yMin  int
+	xMax, yMax  int
+	compression compression
+	lineOrder   int
+	blockLines  int
+
+	tileWidth, tileHeight int
+
+	numChunks int
+	offsets   []uint64
+}
+
+// newPart builds a Part from one already-parsed header, including the
+// chunk count it implies; it does not read the offset table itself,
+// since in a multi-part file every part's header is written before
+// any part's offset table.
+//
+// Single-part files (pre-2.0 and most 2.0 files alike) have no "type"
+// attribute; tiled is the version field's tiled bit instead, so it is
+// passed in explicitly.
+func newPart(ra io.ReaderAt, header map[string]attribute, tiled bool) (*Part, error) {
+	p := &Part{ra: ra, header: header, typ: "scanlineimage"}
+	if tiled {
+		p.typ = "tiledimage"
+	}
+
+	if name, ok := header["name"]; ok {
+		p.name = string(bytes.TrimRight(name.value, "\x00"))
+	}
+	if typ, ok := header["type"]; ok {
+		p.typ = string(bytes.TrimRight(typ.value, "\x00"))
+	}
+
+	channels, ok := header["channels"]
+	if !ok {
+		return nil, FormatError("header does not have 'channels' attribute")
+	}
+	chlist, err := parseChannelList(channels.value)
+	if err != nil {
+		return nil, err
+	}
+	p.channels = chlist
+
+	dataWindow, ok := header["dataWindow"]
+	if !ok {
+		return nil, FormatError("header does not have 'dataWindow' attribute")
+	}
+	p.xMin, p.yMin, p.xMax, p.yMax, err = parseBox2i(dataWindow.value)
+	if err != nil {
+		return nil, err
+	}
+	if p.xMax < p.xMin || p.yMax < p.yMin {
+		return nil, FormatError("dataWindow has xMax < xMin or yMax < yMin")
+	}
+	// width and height are computed from attacker-controlled int32s; an
+	// unchecked product can overflow into a huge or negative pixel
+	// count and turn every per-pixel allocation downstream (DecodeChannel,
+	// Image) into a multi-gigabyte or negative-length make().
+	width := int64(p.xMax-p.xMin) + 1
+	height := int64(p.yMax-p.yMin) + 1
+	if width*height > maxImagePixels {
+		return nil, FormatError("dataWindow is larger than this package supports")
+	}
+
+	comp, ok := header["compression"]
+	if !ok {
+		return nil, FormatError("header does not have 'compression' attribute")
+	}
+	if len(comp.value) < 1 {
+		return nil, FormatError("'compression' attribute is empty")
+	}
+	p.compression = compression(comp.value[0])
+
+	lo, ok := header["lineOrder"]
+	if !ok {
+		return nil, FormatError("header does not have 'lineOrder' attribute")
+	}
+	if len(lo.value) < 1 {
+		return nil, FormatError("'lineOrder' attribute is empty")
+	}
+	p.lineOrder = int(lo.value[0])
+
+	if p.IsTiled() {
+		tiles, ok := header["tiles"]
+		if !ok {
+			return nil, FormatError("header does not have 'tiles' attribute")
+		}
+		if len(tiles.value) < 8 {
+			return nil, FormatError("'tiles' attribute is shorter than 8 bytes")
+		}
+		p.tileWidth = int(parse.Uint32(tiles.value[0:4]))
+		p.tileHeight = int(parse.Uint32(tiles.value[4:8]))
+	} else {
+		blockLines, ok := numLinesPerBlock[p.compression]
+		if !ok {
+			if p.IsDeep() {
+				// Deep scanline parts only support NO_COMPRESSION,
+				// RLE and ZIPS, all one scanline per chunk.
+				blockLines = 1
+			} else {
+				return nil, UnsupportedError(fmt.Sprintf("compression method %v", p.compression))
+			}
+		}
+		p.blockLines = blockLines
+	}
+
+	p.numChunks, err = p.countChunks()
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// countChunks derives the number of chunks the offset table holds
+// from the part's type and data window.
+func (p *Part) countChunks() (int, error) {
+	if p.IsTiled() {
+		if p.tileWidth <= 0 || p.tileHeight <= 0 {
+			return 0, FormatError("invalid tile size")
+		}
+		width := p.xMax - p.xMin + 1
+		height := p.yMax - p.yMin + 1
+		nx := (width + p.tileWidth - 1) / p.tileWidth
+		ny := (height + p.tileHeight - 1) / p.tileHeight
+		// TODO(chunk0-6): only mip level 0 is counted; mip/rip-mapped
+		// tiled parts have additional lower-resolution levels.
+		return nx * ny, nil
+	}
+
+	height := p.yMax - p.yMin + 1
+	n := height / p.blockLines
+	if height%p.blockLines != 0 {
+		n++
+	}
+	return n, nil
+}
+
+// Name returns the part's "name" attribute, or "" if it has none
+// (always true for the implicit part of a single-part file).
+func (p *Part) Name() string { return p.name }
+
+// Type returns the part's "type" attribute: "scanlineimage",
+// "tiledimage", "deepscanline" or "deeptile".
+func (p *Part) Type() string { return p.typ }
+
+// Channels returns the part's channel list.
+func (p *Part) Channels() []channel { return p.channels }
+
+// DataWindow returns the part's data window as (xMin, yMin, xMax, yMax).
+func (p *Part) DataWindow() (xMin, yMin, xMax, yMax int) {
+	return p.xMin, p.yMin, p.xMax, p.yMax
+}
+
+// Compression returns the part's compression method.
+func (p *Part) Compression() compression { return p.compression }
+
+// NumChunks returns the number of chunks in the part's offset table.
+func (p *Part) NumChunks() int { return len(p.offsets) }
+
+// IsTiled reports whether the part stores tiled (as opposed to
+// scanline) data.
+func (p *Part) IsTiled() bool {
+	return p.typ == "tiledimage" || p.typ == "deeptile"
+}
+
+// IsDeep reports whether the part stores deep (multi-sample-per-pixel)
+// data.
+func (p *Part) IsDeep() bool {
+	return p.typ == "deepscanline" || p.typ == "deeptile"
+}
+
+// AsDeep returns p as a DeepPart if it holds deep data, and false
+// otherwise.
+func (p *Part) AsDeep() (*DeepPart, bool) {
+	if !p.IsDeep() {
+		return nil, false
+	}
+	return &DeepPart{Part: p}, true
+}
+
+// parseChannelList decodes a chlist attribute's raw value into a
+// slice of channel.
+func parseChannelList(value []byte) ([]channel, error) {
+	chlist := make([]channel, 0)
+	remain := bufio.NewReader(bytes.NewBuffer(value))
+	for {
+		nameByte, err := remain.ReadBytes(0x00)
+		if err != nil {
+			return nil, err
+		}
+		name := string(nameByte[:len(nameByte)-1])
+
+		channelBytes, err := read(remain, 16)
+		if err != nil {
+			return nil, err
+		}
+		pixelType := int32(parse.Uint32(channelBytes[:4]))
+		pLinear := uint8(channelBytes[4])
+		// channelBytes[5:8] are place holders.
+		xSampling := int32(parse.Uint32(channelBytes[8:12]))
+		ySampling := int32(parse.Uint32(channelBytes[12:]))
+		chlist = append(chlist, channel{
+			name:      name,
+			pixelType: pixelType,
+			pLinear:   pLinear,
+			xSampling: xSampling,
+			ySampling: ySampling,
+		})
+		if remain.Buffered() == 1 {
+			nullByte, err := remain.Peek(1)
+			if err != nil {
+				return nil, err
+			}
+			if nullByte[0] != 0x00 {
+				return nil, FormatError("channels are must seperated by a null byte")
+			}
+			break
+		}
+	}
+	return chlist, nil
+}
+
+// parseBox2i decodes a box2i attribute's raw value. A box2i is always
+// 16 bytes (four int32s); a header lying about that is rejected
+// rather than read out of bounds.
+func parseBox2i(b []byte) (xMin, yMin, xMax, yMax int, err error) {
+	if len(b) < 16 {
+		return 0, 0, 0, 0, FormatError("box2i attribute is shorter than 16 bytes")
+	}
+	return int(parse.Uint32(b[0:4])), int(parse.Uint32(b[4:8])),
+		int(parse.Uint32(b[8:12])), int(parse.Uint32(b[12:16])), nil
+}
+
+// DeepPart is a Part known to hold deep scanline or deep tile data.
+type DeepPart struct {
+	*Part
+}
+
+// deepChunkHeader is the three-int64 prefix OpenEXR 2.0 puts before
+// every deep chunk's sample data, right after the chunk's own
+// coordinate prefix: the packed (compressed) size of the sample count
+// table, then the packed and unpacked sizes of the pixel data itself.
+// The sample count table's unpacked size isn't stored -- it's always
+// one cumulative int32 per pixel in the chunk's row.
+type deepChunkHeader struct {
+	packedSampleCountTableSize int64
+	packedDataSize             int64
+	unpackedDataSize           int64
+}
+
+// readChunkHeader reads the i-th chunk's coordinate prefix and deep
+// header, returning the header and the file offset its sample count
+// table starts at.
+func (p *DeepPart) readChunkHeader(i int) (deepChunkHeader, int64, error) {
+	if i < 0 || i >= len(p.offsets) {
+		return deepChunkHeader{}, 0, FormatError(fmt.Sprintf("chunk index %d out of range", i))
+	}
+	off := int64(p.offsets[i])
+
+	// A deepscanline chunk starts with a 4-byte y coordinate; a
+	// deeptile chunk starts with 4 tile coordinate ints.
+	prefix := 4
+	if p.typ == "deeptile" {
+		prefix = 16
+	}
+
+	hdr := make([]byte, prefix+24)
+	if _, err := p.ra.ReadAt(hdr, off); err != nil {
+		return deepChunkHeader{}, 0, err
+	}
+	b := hdr[prefix:]
+	h := deepChunkHeader{
+		packedSampleCountTableSize: int64(parse.Uint64(b[0:8])),
+		packedDataSize:             int64(parse.Uint64(b[8:16])),
+		unpackedDataSize:           int64(parse.Uint64(b[16:24])),
+	}
+	return h, off + int64(len(hdr)), nil
+}
+
+// SampleCounts returns, for each chunk in order, the number of deep
+// samples at each pixel of that chunk's row. The sample count table
+// is itself compressed with the part's compression method;
+// SampleCounts decompresses it, but does not unpack pixel sample data
+// -- see Samples for that.
+func (p *DeepPart) SampleCounts() ([][]uint32, error) {
+	codec, ok := codecs[p.compression]
+	if !ok {
+		return nil, UnsupportedError(fmt.Sprintf("compression method %v", p.compression))
+	}
+
+	width := p.xMax - p.xMin + 1
+	counts := make([][]uint32, len(p.offsets))
+	for i := range p.offsets {
+		hdr, dataOff, err := p.readChunkHeader(i)
+		if err != nil {
+			return nil, err
+		}
+
+		packed := make([]byte, hdr.packedSampleCountTableSize)
+		if _, err := p.ra.ReadAt(packed, dataOff); err != nil {
+			return nil, err
+		}
+
+		// The table is one cumulative int32 per pixel in the row; its
+		// unpacked size is never stored explicitly.
+		unpacked := make([]byte, width*4)
+		if err := codec.Decompress(unpacked, packed, width*4, 1); err != nil {
+			return nil, err
+		}
+
+		// The table stores, per pixel, the cumulative sample count up
+		// to and including that pixel as a 4-byte int; turn it into a
+		// per-pixel count.
+		row := make([]uint32, width)
+		prev := uint32(0)
+		for x := 0; x < width; x++ {
+			cum := parse.Uint32(unpacked[x*4 : x*4+4])
+			row[x] = cum - prev
+			prev = cum
+		}
+		counts[i] = row
+	}
+	return counts, nil
+}
+
+// channel returns the part's channel named name, or nil if it has
+// none.
+func (p *Part) channel(name string) *channel {
+	for i := range p.channels {
+		if p.channels[i].name == name {
+			return &p.channels[i]
+		}
+	}
+	return nil
+}
+
+// Samples returns, for each chunk, the decompressed samples of the
+// named channel in per-pixel sample order.
+//
+// A chunk's pixel data holds one plane per channel, in channel list
+// order, each plane the concatenation of that channel's samples for
+// every pixel in the chunk's row -- unlike a regular scanline, where
+// channels are interleaved. SampleCounts gives the per-pixel sample
+// counts needed to find each channel's plane boundaries.
+func (p *DeepPart) Samples(channelName string) ([][]float32, error) {
+	ch := p.channel(channelName)
+	if ch == nil {
+		return nil, FormatError(fmt.Sprintf("no such channel %q", channelName))
+	}
+	codec, ok := codecs[p.compression]
+	if !ok {
+		return nil, UnsupportedError(fmt.Sprintf("compression method %v", p.compression))
+	}
+	counts, err := p.SampleCounts()
+	if err != nil {
+		return nil, err
+	}
+
+	width := p.xMax - p.xMin + 1
+	samples := make([][]float32, len(p.offsets))
+	for i := range p.offsets {
+		hdr, sampleCountOff, err := p.readChunkHeader(i)
+		if err != nil {
+			return nil, err
+		}
+		pixelDataOff := sampleCountOff + hdr.packedSampleCountTableSize
+
+		packed := make([]byte, hdr.packedDataSize)
+		if _, err := p.ra.ReadAt(packed, pixelDataOff); err != nil {
+			return nil, err
+		}
+		unpacked := make([]byte, hdr.unpackedDataSize)
+		if err := codec.Decompress(unpacked, packed, int(hdr.unpackedDataSize), 1); err != nil {
+			return nil, err
+		}
+
+		off := 0
+		var rowSamples []float32
+		for _, c := range p.channels {
+			total := 0
+			for x := 0; x < width; x++ {
+				total += int(counts[i][x])
+			}
+			sampleSize := bytesPerSample(c.pixelType)
+			planeSize := total * sampleSize
+
+			if c.name == channelName {
+				rowSamples = make([]float32, total)
+				for s := 0; s < total; s++ {
+					b := unpacked[off+s*sampleSize : off+(s+1)*sampleSize]
+					rowSamples[s] = sampleToFloat32(c.pixelType, b)
+				}
+				break
+			}
+			off += planeSize
+		}
+		samples[i] = rowSamples
+	}
+	return samples, nil
+}
@@ -0,0 +1,127 @@
+package exr
+
+import (
+	"bytes"
+	"os"
+	"testing"
+)
+
+func openFixture(t *testing.T, path string) *Reader {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile(%q): %v", path, err)
+	}
+	r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("NewReader(%q): %v", path, err)
+	}
+	return r
+}
+
+// TestDeepPartSampleCounts and TestDeepPartSamples exercise deep
+// chunk parsing against testdata/deep_sample.exr: a single-part,
+// 3x2, one-channel ("Z", FLOAT) deepscanline image with
+// NO_COMPRESSION, built by hand rather than through Writer (which
+// only emits regular scanline chunks).
+func TestDeepPartSampleCounts(t *testing.T) {
+	r := openFixture(t, "testdata/deep_sample.exr")
+	parts := r.Parts()
+	if len(parts) != 1 {
+		t.Fatalf("Parts() = %d parts, want 1", len(parts))
+	}
+	deep, ok := parts[0].AsDeep()
+	if !ok {
+		t.Fatalf("Parts()[0].AsDeep() = false, want true")
+	}
+
+	counts, err := deep.SampleCounts()
+	if err != nil {
+		t.Fatalf("SampleCounts: %v", err)
+	}
+	want := [][]uint32{
+		{1, 2, 1},
+		{0, 1, 3},
+	}
+	if len(counts) != len(want) {
+		t.Fatalf("SampleCounts() = %d rows, want %d", len(counts), len(want))
+	}
+	for i := range want {
+		if len(counts[i]) != len(want[i]) {
+			t.Fatalf("SampleCounts()[%d] = %v, want %v", i, counts[i], want[i])
+		}
+		for x := range want[i] {
+			if counts[i][x] != want[i][x] {
+				t.Errorf("SampleCounts()[%d][%d] = %d, want %d", i, x, counts[i][x], want[i][x])
+			}
+		}
+	}
+}
+
+func TestDeepPartSamples(t *testing.T) {
+	r := openFixture(t, "testdata/deep_sample.exr")
+	deep, ok := r.Parts()[0].AsDeep()
+	if !ok {
+		t.Fatalf("Parts()[0].AsDeep() = false, want true")
+	}
+
+	samples, err := deep.Samples("Z")
+	if err != nil {
+		t.Fatalf("Samples: %v", err)
+	}
+	want := [][]float32{
+		{1, 2, 3, 4},
+		{5, 6, 7, 8},
+	}
+	if len(samples) != len(want) {
+		t.Fatalf("Samples() = %d rows, want %d", len(samples), len(want))
+	}
+	for i := range want {
+		if len(samples[i]) != len(want[i]) {
+			t.Fatalf("Samples()[%d] = %v, want %v", i, samples[i], want[i])
+		}
+		for x := range want[i] {
+			if samples[i][x] != want[i][x] {
+				t.Errorf("Samples()[%d][%d] = %v, want %v", i, x, samples[i][x], want[i][x])
+			}
+		}
+	}
+
+	if _, err := deep.Samples("nope"); err == nil {
+		t.Error("Samples(\"nope\"): expected error for unknown channel, got nil")
+	}
+}
+
+// TestReaderParts exercises the multi-part header-parsing path
+// against testdata/multipart_sample.exr: two scanlineimage parts
+// ("beauty", "depth") with distinct data windows.
+func TestReaderParts(t *testing.T) {
+	r := openFixture(t, "testdata/multipart_sample.exr")
+	parts := r.Parts()
+	if len(parts) != 2 {
+		t.Fatalf("Parts() = %d parts, want 2", len(parts))
+	}
+
+	if got, want := parts[0].Name(), "beauty"; got != want {
+		t.Errorf("Parts()[0].Name() = %q, want %q", got, want)
+	}
+	if got, want := parts[1].Name(), "depth"; got != want {
+		t.Errorf("Parts()[1].Name() = %q, want %q", got, want)
+	}
+
+	xMin, yMin, xMax, yMax := parts[0].DataWindow()
+	if xMin != 0 || yMin != 0 || xMax != 2 || yMax != 1 {
+		t.Errorf("Parts()[0].DataWindow() = (%d,%d,%d,%d), want (0,0,2,1)", xMin, yMin, xMax, yMax)
+	}
+	xMin, yMin, xMax, yMax = parts[1].DataWindow()
+	if xMin != 0 || yMin != 0 || xMax != 1 || yMax != 0 {
+		t.Errorf("Parts()[1].DataWindow() = (%d,%d,%d,%d), want (0,0,1,0)", xMin, yMin, xMax, yMax)
+	}
+
+	if got, want := parts[0].NumChunks(), 2; got != want {
+		t.Errorf("Parts()[0].NumChunks() = %d, want %d", got, want)
+	}
+	if got, want := parts[1].NumChunks(), 1; got != want {
+		t.Errorf("Parts()[1].NumChunks() = %d, want %d", got, want)
+	}
+}
@@ -0,0 +1,281 @@
+package exr
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Reader provides random access to the chunks of an EXR file.
+//
+// Unlike Decode, which historically read a file sequentially from
+// start to end, Reader parses only the header and the chunk offset
+// table up front, then lets callers fetch individual scanline blocks
+// or tiles on demand. This mirrors how io.NewSectionReader and
+// archive/zip.Reader let a caller work with one entry of a larger
+// file without scanning the whole thing.
+type Reader struct {
+	ra   io.ReaderAt
+	size int64
+
+	vf     VersionField
+	header map[string]attribute
+
+	// channels, the window, compression, blockLines, lineOrder and
+	// offsets below mirror parts[0] and are only populated for
+	// single-part files; they exist so the random-access API added
+	// for chunk0-1 doesn't need updating for every single-part caller.
+	// Multi-part files must go through Parts instead.
+	channels    []channel
+	xMin, yMin  int
+	xMax, yMax  int
+	compression compression
+	blockLines  int
+	lineOrder   int
+	offsets     []uint64
+
+	parts []*Part
+
+	// concurrency is how many goroutines Image and DecodeChannel use
+	// to decompress chunks. Set via SetConcurrency; defaults to 1.
+	concurrency int
+}
+
+// NewReader parses the header and chunk offset table of the EXR image
+// read through ra. size must be the total length of the underlying
+// data; it bounds the section readers handed out by ScanlineBlock and
+// Tile.
+//
+// NewReader does not read any pixel data, so opening a multi-gigabyte
+// frame is cheap; pixels are only decompressed when a chunk is
+// requested.
+func NewReader(ra io.ReaderAt, size int64) (*Reader, error) {
+	r := bufio.NewReader(io.NewSectionReader(ra, 0, size))
+
+	magicByte, err := read(r, 4)
+	if err != nil {
+		return nil, err
+	}
+	magic := int(parse.Uint32(magicByte))
+	if magic != MagicNumber {
+		return nil, FormatError("wrong magic number")
+	}
+
+	versionBytes, err := read(r, 4)
+	if err != nil {
+		return nil, err
+	}
+	versionNum := int(parse.Uint32(versionBytes))
+
+	vf := VersionField{
+		version:   int(versionBytes[0]),
+		tiled:     versionNum&0x200 != 0,
+		longName:  versionNum&0x400 != 0,
+		deep:      versionNum&0x800 != 0,
+		multiPart: versionNum&0x1000 != 0,
+	}
+	if vf.tiled {
+		if vf.deep {
+			return nil, FormatError("single tile bit is on, non-image bit should be off")
+		}
+		if vf.multiPart {
+			return nil, FormatError("single tile bit is on, multi-part bit should be off")
+		}
+	}
+
+	// Parse one header per part. Single-part files always have
+	// exactly one; multi-part files terminate the list with an extra
+	// null byte once every part's header has been read.
+	//
+	// remaining tracks actual bytes left in the stream (starting right
+	// after the 8-byte magic number and version field) so that
+	// parseAttribute's size bound stays meaningful cumulatively: without
+	// decrementing it per attribute, a header with many attributes each
+	// claiming a size just under the file's total length would pass the
+	// per-attribute check while allocating far more memory in total
+	// than the file could possibly hold.
+	remaining := size - 8
+	var headers []map[string]attribute
+	for {
+		header := make(map[string]attribute)
+		for {
+			pAttr, err := parseAttribute(r, parse, vf.longName, remaining)
+			if err != nil {
+				return nil, err
+			}
+			if pAttr == nil {
+				remaining--
+				break
+			}
+			remaining -= int64(len(pAttr.name)) + 1 + int64(len(pAttr.typ)) + 1 + 4 + int64(pAttr.size)
+			header[pAttr.name] = *pAttr
+		}
+		headers = append(headers, header)
+
+		if !vf.multiPart {
+			break
+		}
+		bs, err := r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if bs[0] == 0x00 {
+			if _, err := read(r, 1); err != nil { // consume the terminator
+				return nil, err
+			}
+			remaining--
+			break
+		}
+	}
+
+	parts := make([]*Part, len(headers))
+	for i, header := range headers {
+		p, err := newPart(ra, header, vf.tiled)
+		if err != nil {
+			return nil, err
+		}
+		// A part's offset table is 8 bytes per chunk; a crafted
+		// dataWindow or tile size can otherwise inflate numChunks
+		// (derived from attacker-controlled dimensions) far past what
+		// the file could possibly hold, turning the make([]uint64, ...)
+		// below into a multi-gigabyte allocation.
+		if p.numChunks < 0 || int64(p.numChunks)*8 > size {
+			return nil, FormatError("part's chunk count is larger than the file")
+		}
+		parts[i] = p
+	}
+	for _, p := range parts {
+		p.offsets = make([]uint64, p.numChunks)
+		for i := range p.offsets {
+			offsetByte, err := read(r, 8)
+			if err != nil {
+				return nil, err
+			}
+			p.offsets[i] = parse.Uint64(offsetByte)
+		}
+	}
+
+	rd := &Reader{ra: ra, size: size, vf: vf, header: headers[0], parts: parts, concurrency: 1}
+	if !vf.multiPart {
+		p := parts[0]
+		rd.channels = p.channels
+		rd.xMin, rd.yMin, rd.xMax, rd.yMax = p.xMin, p.yMin, p.xMax, p.yMax
+		rd.compression = p.compression
+		rd.blockLines = p.blockLines
+		rd.lineOrder = p.lineOrder
+		rd.offsets = p.offsets
+	}
+
+	return rd, nil
+}
+
+// Parts returns every part of the file. Single-part files report
+// exactly one Part, built from the same header Reader itself uses.
+func (r *Reader) Parts() []*Part {
+	return r.parts
+}
+
+// Scanline line order values, as stored in the 1-byte lineOrder
+// attribute.
+const (
+	increasingY = 0
+	decreasingY = 1
+	randomY     = 2
+)
+
+// SetConcurrency sets how many goroutines Image and DecodeChannel use
+// to decompress chunks concurrently. n <= 0 is treated as 1, which is
+// also the default.
+func (r *Reader) SetConcurrency(n int) {
+	if n <= 0 {
+		n = 1
+	}
+	r.concurrency = n
+}
+
+// scanlineBlockY returns the y coordinate stored in the i-th scanline
+// block's own header, which is authoritative regardless of the order
+// the offset table lists chunks in (lineOrder may be DECREASING_Y or
+// even RANDOM_Y).
+func (r *Reader) scanlineBlockY(i int) (int, error) {
+	hdr := make([]byte, 4)
+	if _, err := r.ra.ReadAt(hdr, int64(r.offsets[i])); err != nil {
+		return 0, err
+	}
+	return int(parse.Uint32(hdr)), nil
+}
+
+// Channels returns the channel list declared in the header. For
+// multi-part files it is empty; use Parts instead.
+func (r *Reader) Channels() []channel {
+	return r.channels
+}
+
+// DataWindow returns the image's data window as (xMin, yMin, xMax,
+// yMax). For multi-part files it is zero; use Parts instead.
+func (r *Reader) DataWindow() (xMin, yMin, xMax, yMax int) {
+	return r.xMin, r.yMin, r.xMax, r.yMax
+}
+
+// NumChunks returns the number of chunks (scanline blocks or tiles)
+// addressable through the offset table.
+func (r *Reader) NumChunks() int {
+	return len(r.offsets)
+}
+
+// ScanlineBlock returns a reader over the compressed bytes of the i-th
+// scanline block, as laid out in the offset table. It is an error to
+// call ScanlineBlock on a tiled image; use Tile instead.
+func (r *Reader) ScanlineBlock(i int) (io.Reader, error) {
+	if r.vf.multiPart {
+		return nil, UnsupportedError("ScanlineBlock on a multi-part file, use Parts instead")
+	}
+	if r.vf.tiled {
+		return nil, UnsupportedError("image is tiled, use Tile instead")
+	}
+	if i < 0 || i >= len(r.offsets) {
+		return nil, FormatError(fmt.Sprintf("scanline block index %d out of range", i))
+	}
+
+	// A scanline block is prefixed by [int32 y][int32 dataSize].
+	off := int64(r.offsets[i])
+	hdr := make([]byte, 8)
+	if _, err := r.ra.ReadAt(hdr, off); err != nil {
+		return nil, err
+	}
+	dataSize := int64(parse.Uint32(hdr[4:8]))
+	return io.NewSectionReader(r.ra, off+8, dataSize), nil
+}
+
+// Tile returns a reader over the compressed bytes of the tile at tile
+// coordinate (x, y) in mip/rip level (level, level). Only level 0 of
+// single-resolution tiled images is currently supported; higher
+// levels return an UnsupportedError.
+func (r *Reader) Tile(x, y, level int) (io.Reader, error) {
+	if r.vf.multiPart {
+		return nil, UnsupportedError("Tile on a multi-part file, use Parts instead")
+	}
+	if !r.vf.tiled {
+		return nil, UnsupportedError("image is not tiled, use ScanlineBlock instead")
+	}
+	if level != 0 {
+		return nil, UnsupportedError("mip/rip tile levels")
+	}
+
+	// A tile is prefixed by
+	// [int32 tileX][int32 tileY][int32 levelX][int32 levelY][int32 dataSize].
+	for _, off := range r.offsets {
+		hdr := make([]byte, 20)
+		if _, err := r.ra.ReadAt(hdr, int64(off)); err != nil {
+			return nil, err
+		}
+		tileX := int(parse.Uint32(hdr[0:4]))
+		tileY := int(parse.Uint32(hdr[4:8]))
+		if tileX != x || tileY != y {
+			continue
+		}
+		dataSize := int64(parse.Uint32(hdr[16:20]))
+		return io.NewSectionReader(r.ra, int64(off)+20, dataSize), nil
+	}
+	return nil, FormatError(fmt.Sprintf("no tile at (%d, %d)", x, y))
+}
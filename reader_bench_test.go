@@ -0,0 +1,59 @@
+package exr
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+)
+
+// benchImage builds a ZIP-compressed, multi-megapixel single-channel
+// fixture once and returns its encoded bytes.
+func benchImage(b *testing.B) []byte {
+	const width, height = 2048, 1024 // ~2 megapixels
+
+	y := make([]float32, width*height)
+	for i := range y {
+		y[i] = float32(i%1024) / 1024
+	}
+
+	var buf bytes.Buffer
+	wr := NewWriter(&buf)
+	wr.SetCompression(ZIP_COMPRESSION)
+	wr.SetDataWindow(0, 0, width-1, height-1)
+	wr.AddChannel(Channel{Name: "Y", PixelType: 2})
+	if err := wr.WriteScanlines(map[string][]float32{"Y": y}); err != nil {
+		b.Fatalf("WriteScanlines: %v", err)
+	}
+	if err := wr.Close(); err != nil {
+		b.Fatalf("Close: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func benchmarkDecodeChannel(b *testing.B, concurrency int) {
+	data := benchImage(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			b.Fatalf("NewReader: %v", err)
+		}
+		r.SetConcurrency(concurrency)
+		if _, err := r.DecodeChannel("Y"); err != nil {
+			b.Fatalf("DecodeChannel: %v", err)
+		}
+	}
+}
+
+// BenchmarkDecodeChannelSerial decodes the fixture with a single
+// goroutine (SetConcurrency's default).
+func BenchmarkDecodeChannelSerial(b *testing.B) {
+	benchmarkDecodeChannel(b, 1)
+}
+
+// BenchmarkDecodeChannelParallel decodes the fixture with one
+// goroutine per CPU, to measure how much the worker pool added in
+// chunk0-4 actually helps.
+func BenchmarkDecodeChannelParallel(b *testing.B) {
+	benchmarkDecodeChannel(b, runtime.NumCPU())
+}
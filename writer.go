@@ -0,0 +1,324 @@
+package exr
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// Attribute is a single EXR header attribute, stored as its type name
+// and already-encoded value bytes -- the same representation Reader
+// parses headers into internally.
+type Attribute struct {
+	Type  string
+	Value []byte
+}
+
+// Channel describes one channel a Writer will emit.
+type Channel struct {
+	Name      string
+	PixelType int32 // 0: UINT, 1: HALF, 2: FLOAT
+	PLinear   uint8
+	XSampling int32
+	YSampling int32
+}
+
+// Writer writes a single-part scanline EXR image.
+//
+// Writer follows the same Writer/Close shape as archive/zip.Writer:
+// callers set the header and channel list, stream scanline data, and
+// Close assembles the final file. Unlike zip, whose central directory
+// trails the data it describes, EXR's chunk offset table comes first
+// in the file, so Writer buffers compressed chunks in memory and only
+// touches the underlying io.Writer once Close backpatches the real
+// offsets.
+type Writer struct {
+	w io.Writer
+
+	attrs       map[string]Attribute
+	channels    []Channel
+	compression compression
+
+	xMin, yMin int
+	xMax, yMax int
+
+	chunks [][]byte // compressed scanline blocks, in chunk order
+
+	closed bool
+}
+
+// NewWriter returns a Writer that will emit a single-part scanline EXR
+// image to w once Close is called.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w, attrs: make(map[string]Attribute)}
+}
+
+// SetHeader merges attrs into the image's header. channels and
+// compression are managed by AddChannel and SetCompression instead
+// and are ignored here.
+func (wr *Writer) SetHeader(attrs map[string]Attribute) {
+	for name, attr := range attrs {
+		if name == "channels" || name == "compression" {
+			continue
+		}
+		wr.attrs[name] = attr
+	}
+}
+
+// SetCompression chooses the chunk compression method. It defaults to
+// NO_COMPRESSION.
+func (wr *Writer) SetCompression(c compression) {
+	wr.compression = c
+}
+
+// SetDataWindow sets the image's data and display window.
+func (wr *Writer) SetDataWindow(xMin, yMin, xMax, yMax int) {
+	wr.xMin, wr.yMin, wr.xMax, wr.yMax = xMin, yMin, xMax, yMax
+}
+
+// AddChannel appends ch to the channel list. EXR requires the channel
+// list be sorted by name, so AddChannel re-sorts wr.channels after
+// every call rather than trusting callers to add them in order.
+func (wr *Writer) AddChannel(ch Channel) {
+	wr.channels = append(wr.channels, ch)
+	sort.Slice(wr.channels, func(i, j int) bool {
+		return wr.channels[i].Name < wr.channels[j].Name
+	})
+}
+
+// WriteScanlines compresses and buffers one full image's worth of
+// scanlines. data must have one entry per added channel holding
+// width*height float32 samples in scanline order; samples written to
+// a HALF channel are rounded to half precision.
+func (wr *Writer) WriteScanlines(data map[string][]float32) error {
+	if wr.closed {
+		return fmt.Errorf("exr: WriteScanlines called on a closed Writer")
+	}
+	width := wr.xMax - wr.xMin + 1
+	height := wr.yMax - wr.yMin + 1
+	for _, ch := range wr.channels {
+		if got, want := len(data[ch.Name]), width*height; got != want {
+			return FormatError(fmt.Sprintf("channel %q: got %d samples, want %d", ch.Name, got, want))
+		}
+	}
+
+	blockLines, ok := numLinesPerBlock[wr.compression]
+	if !ok {
+		return UnsupportedError(fmt.Sprintf("compression method %v", wr.compression))
+	}
+
+	layout := make(map[string]int, len(wr.channels))
+	lineSize := 0
+	for _, ch := range wr.channels {
+		layout[ch.Name] = lineSize
+		lineSize += bytesPerSample(ch.PixelType) * width
+	}
+
+	for y0 := 0; y0 < height; y0 += blockLines {
+		lines := blockLines
+		if y0+lines > height {
+			lines = height - y0
+		}
+
+		raw := make([]byte, lineSize*lines)
+		for _, ch := range wr.channels {
+			off := layout[ch.Name]
+			size := bytesPerSample(ch.PixelType)
+			samples := data[ch.Name]
+			for line := 0; line < lines; line++ {
+				base := line*lineSize + off
+				for x := 0; x < width; x++ {
+					putSample(raw[base+x*size:base+(x+1)*size], ch.PixelType, samples[(y0+line)*width+x])
+				}
+			}
+		}
+
+		compressed, err := wr.compressBlock(raw)
+		if err != nil {
+			return err
+		}
+
+		y := wr.yMin + y0
+		chunk := make([]byte, 8+len(compressed))
+		parse.PutUint32(chunk[0:4], uint32(y))
+		parse.PutUint32(chunk[4:8], uint32(len(compressed)))
+		copy(chunk[8:], compressed)
+		wr.chunks = append(wr.chunks, chunk)
+	}
+	return nil
+}
+
+// compressBlock compresses one raw, channel-interleaved scanline
+// block according to wr.compression.
+func (wr *Writer) compressBlock(raw []byte) ([]byte, error) {
+	switch wr.compression {
+	case NO_COMPRESSION:
+		return raw, nil
+	case ZIP_COMPRESSION, ZIPS_COMPRESSION:
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		if _, err := zw.Write(predict(interleave(raw))); err != nil {
+			return nil, err
+		}
+		if err := zw.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, UnsupportedError(fmt.Sprintf("writing with compression method %v", wr.compression))
+	}
+}
+
+// putSample encodes v into b according to pixelType.
+func putSample(b []byte, pixelType int32, v float32) {
+	switch pixelType {
+	case 1: // HALF
+		parse.PutUint16(b, float32ToHalf(v))
+	case 2: // FLOAT
+		parse.PutUint32(b, math.Float32bits(v))
+	default: // UINT
+		parse.PutUint32(b, uint32(v))
+	}
+}
+
+// predict applies EXR's forward byte-delta prediction pass, the
+// inverse of unpredict: out[i] = p[i] - p[i-1] + 128 (mod 256).
+func predict(p []byte) []byte {
+	out := make([]byte, len(p))
+	copy(out, p)
+	for i := len(out) - 1; i >= 1; i-- {
+		out[i] = out[i] - out[i-1] + 128
+	}
+	return out
+}
+
+// interleave applies EXR's forward even/odd byte split, the inverse
+// of deinterleave: even-indexed bytes are packed into the first half
+// of the result and odd-indexed bytes into the second half.
+func interleave(p []byte) []byte {
+	out := make([]byte, len(p))
+	half := (len(p) + 1) / 2
+	for i, b := range p {
+		if i%2 == 0 {
+			out[i/2] = b
+		} else {
+			out[half+i/2] = b
+		}
+	}
+	return out
+}
+
+// Close writes the assembled header, chunk offset table and
+// compressed chunk data to the underlying io.Writer. Close is
+// idempotent; calling it more than once is a no-op.
+func (wr *Writer) Close() error {
+	if wr.closed {
+		return nil
+	}
+	wr.closed = true
+
+	headerBytes := wr.encodeHeader()
+
+	buf := bufio.NewWriter(wr.w)
+
+	magicBytes := make([]byte, 4)
+	parse.PutUint32(magicBytes, uint32(MagicNumber))
+	if _, err := buf.Write(magicBytes); err != nil {
+		return err
+	}
+
+	versionBytes := make([]byte, 4)
+	parse.PutUint32(versionBytes, 2) // version 2, single-part scanline, no flag bits.
+	if _, err := buf.Write(versionBytes); err != nil {
+		return err
+	}
+
+	if _, err := buf.Write(headerBytes); err != nil {
+		return err
+	}
+
+	// Chunk i starts right after the offset table, plus the combined
+	// size of every chunk before it.
+	offset := int64(len(magicBytes)+len(versionBytes)+len(headerBytes)) + 8*int64(len(wr.chunks))
+	for _, chunk := range wr.chunks {
+		offsetBytes := make([]byte, 8)
+		parse.PutUint64(offsetBytes, uint64(offset))
+		if _, err := buf.Write(offsetBytes); err != nil {
+			return err
+		}
+		offset += int64(len(chunk))
+	}
+	for _, chunk := range wr.chunks {
+		if _, err := buf.Write(chunk); err != nil {
+			return err
+		}
+	}
+
+	return buf.Flush()
+}
+
+// encodeHeader assembles the attribute list: the mandatory attributes
+// derived from the channel list, compression and data window, any
+// extra attributes from SetHeader, and the trailing null byte that
+// terminates a single-part header.
+func (wr *Writer) encodeHeader() []byte {
+	var buf bytes.Buffer
+	writeAttr := func(name, typ string, value []byte) {
+		buf.WriteString(name)
+		buf.WriteByte(0)
+		buf.WriteString(typ)
+		buf.WriteByte(0)
+		sizeBytes := make([]byte, 4)
+		parse.PutUint32(sizeBytes, uint32(len(value)))
+		buf.Write(sizeBytes)
+		buf.Write(value)
+	}
+
+	writeAttr("channels", "chlist", encodeChannels(wr.channels))
+	writeAttr("compression", "compression", []byte{byte(wr.compression)})
+	writeAttr("dataWindow", "box2i", encodeBox2i(wr.xMin, wr.yMin, wr.xMax, wr.yMax))
+	writeAttr("displayWindow", "box2i", encodeBox2i(wr.xMin, wr.yMin, wr.xMax, wr.yMax))
+	writeAttr("lineOrder", "lineOrder", []byte{0}) // INCREASING_Y
+
+	floatBytes := make([]byte, 4)
+	parse.PutUint32(floatBytes, math.Float32bits(1))
+	writeAttr("pixelAspectRatio", "float", floatBytes)
+	writeAttr("screenWindowCenter", "v2f", make([]byte, 8))
+	writeAttr("screenWindowWidth", "float", floatBytes)
+
+	for name, attr := range wr.attrs {
+		writeAttr(name, attr.Type, attr.Value)
+	}
+
+	buf.WriteByte(0) // end of header
+	return buf.Bytes()
+}
+
+func encodeChannels(channels []Channel) []byte {
+	var buf bytes.Buffer
+	for _, ch := range channels {
+		buf.WriteString(ch.Name)
+		buf.WriteByte(0)
+		b := make([]byte, 16)
+		parse.PutUint32(b[0:4], uint32(ch.PixelType))
+		b[4] = ch.PLinear
+		parse.PutUint32(b[8:12], uint32(ch.XSampling))
+		parse.PutUint32(b[12:16], uint32(ch.YSampling))
+		buf.Write(b)
+	}
+	buf.WriteByte(0)
+	return buf.Bytes()
+}
+
+func encodeBox2i(xMin, yMin, xMax, yMax int) []byte {
+	b := make([]byte, 16)
+	parse.PutUint32(b[0:4], uint32(xMin))
+	parse.PutUint32(b[4:8], uint32(yMin))
+	parse.PutUint32(b[8:12], uint32(xMax))
+	parse.PutUint32(b[12:16], uint32(yMax))
+	return b
+}